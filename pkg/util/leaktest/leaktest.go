@@ -0,0 +1,152 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package leaktest provides a helper for tests to verify that no
+// goroutines are leaked after the test completes.
+package leaktest
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakCheckDeadline bounds how long AfterTest's returned func waits
+// for goroutines that are merely slow to exit (as opposed to
+// genuinely leaked) to go away before failing the test.
+const leakCheckDeadline = 5 * time.Second
+
+// ignoredStacks are substrings of a goroutine's stack trace that
+// mark it as not interesting to leak detection: either it's part of
+// the testing/runtime machinery itself, or it's a known
+// package-level background worker that is deliberately started once
+// for the lifetime of the process (and so will still be running when
+// later, unrelated tests check for leaks).
+var ignoredStacks = []string{
+	"testing.Main(",
+	"testing.(*T).Run(",
+	"testing.RunTests(",
+	"testing.(*M).Run(",
+	"runtime.goexit",
+	// The log package's retention janitor is started lazily via
+	// sync.Once the first time any SecondaryLogger calls
+	// WithRetention, and intentionally runs for the life of the
+	// process; it is not something any individual test can or
+	// should stop.
+	"log.runJanitor(",
+}
+
+// interestingGoroutines returns the stack traces of every
+// currently-running goroutine that isn't filtered out by
+// ignoredStacks, sorted for stable comparison. The calling goroutine
+// itself is always excluded: AfterTest takes its "before" snapshot
+// from the test goroutine directly, and its "after" snapshot from
+// inside the closure it returns, which runs on that same goroutine
+// but one frame deeper (testing.tRunner -> the test func ->
+// AfterTest vs. ... -> AfterTest.func1); the stack text differs even
+// though it's not a leak, so it must be dropped by goroutine ID
+// rather than compared.
+func interestingGoroutines() []string {
+	selfID := currentGoroutineID()
+
+	buf := make([]byte, 2<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	var stacks []string
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			continue
+		}
+		if goroutineID(g) == selfID {
+			continue
+		}
+		ignored := false
+		for _, s := range ignoredStacks {
+			if strings.Contains(g, s) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			stacks = append(stacks, g)
+		}
+	}
+	sort.Strings(stacks)
+	return stacks
+}
+
+// currentGoroutineID returns the ID of the calling goroutine, parsed
+// out of its own stack trace header ("goroutine 123 [running]:").
+func currentGoroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	return goroutineID(string(buf))
+}
+
+// goroutineID extracts the goroutine ID from the header line of a
+// single goroutine's stack trace ("goroutine 123 [running]:"). It
+// returns -1 if the header can't be parsed, which never matches a
+// real ID and so never causes a goroutine to be filtered out.
+func goroutineID(stack string) int64 {
+	fields := strings.Fields(stack)
+	if len(fields) < 2 {
+		return -1
+	}
+	id, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+// AfterTest snapshots the currently-running goroutines and returns a
+// function to be run at the end of the test via defer, which checks
+// that no new (non-ignored) goroutines are still running. Goroutines
+// that are merely shutting down asynchronously are given up to
+// leakCheckDeadline to finish before being reported as a leak.
+func AfterTest(t testing.TB) func() {
+	before := interestingGoroutines()
+	return func() {
+		if t.Failed() {
+			return
+		}
+		var leaked []string
+		deadline := time.Now().Add(leakCheckDeadline)
+		for {
+			leaked = diff(before, interestingGoroutines())
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if len(leaked) > 0 {
+			t.Errorf("test leaked %d goroutine(s):\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+		}
+	}
+}
+
+// diff returns the entries of after that are not present in before.
+func diff(before, after []string) []string {
+	seen := make(map[string]bool, len(before))
+	for _, g := range before {
+		seen[g] = true
+	}
+	var out []string
+	for _, g := range after {
+		if !seen[g] {
+			out = append(out, g)
+		}
+	}
+	return out
+}
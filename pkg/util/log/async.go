@@ -0,0 +1,183 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy selects what an asyncLogger does when its bounded
+// queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the caller's goroutine wait for room in the
+	// queue, the same backpressure the synchronous path applies
+	// today.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the entry that didn't fit, keeping
+	// everything already queued.
+	OverflowDropNewest
+	// OverflowDropOldest evicts the longest-queued entry to make room
+	// for the new one.
+	OverflowDropOldest
+	// OverflowDropAndCount behaves like OverflowDropNewest, but also
+	// increments a counter retrievable via
+	// SecondaryLogger.AsyncDroppedCount, so callers can at least
+	// observe how much was lost.
+	OverflowDropAndCount
+)
+
+// defaultAsyncDrainDeadline bounds how long Close waits for the
+// queue to empty before it stops the background goroutine. Stopping
+// it still emits every entry left in the queue (see run), so Close
+// can take longer than this when the sinks are slow.
+const defaultAsyncDrainDeadline = 5 * time.Second
+
+// asyncLogger buffers Entry values produced by a SecondaryLogger in
+// a bounded channel, draining them to the logger's sinks from a
+// single background goroutine. This moves the disk I/O performed by
+// loggerT.emit off of the caller's goroutine, at the cost of the
+// overflow behavior selected by OverflowPolicy when producers
+// outrun the drainer.
+type asyncLogger struct {
+	logger *loggerT
+	policy OverflowPolicy
+	queue  chan Entry
+
+	dropped uint64 // atomic; only meaningful under OverflowDropAndCount
+
+	// pending counts entries that have been handed to the background
+	// goroutine (enqueued successfully, and not since evicted under
+	// OverflowDropOldest) but not yet passed to logger.emit. drain watches this, not the channel's
+	// length: len(a.queue) hits zero as soon as run's select removes
+	// an entry, which is before emit (the actual disk write) has
+	// happened, so waiting on queue length lets drain return before
+	// the last entry or two have actually made it to the sinks.
+	pending int64 // atomic
+
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+}
+
+func newAsyncLogger(logger *loggerT, queueSize int, flushInterval time.Duration, policy OverflowPolicy) *asyncLogger {
+	a := &asyncLogger{
+		logger:    logger,
+		policy:    policy,
+		queue:     make(chan Entry, queueSize),
+		stopCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+	}
+	go a.run(flushInterval)
+	return a
+}
+
+func (a *asyncLogger) run(flushInterval time.Duration) {
+	defer close(a.stoppedCh)
+
+	var tickCh <-chan time.Time
+	if flushInterval > 0 {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		tickCh = ticker.C
+	}
+
+	for {
+		select {
+		case entry := <-a.queue:
+			a.logger.emit(entry)
+			atomic.AddInt64(&a.pending, -1)
+		case <-tickCh:
+			_ = a.logger.flush()
+		case <-a.stopCh:
+			// Drain whatever is left before exiting so that Close
+			// doesn't lose entries that were queued right before
+			// shutdown.
+			for {
+				select {
+				case entry := <-a.queue:
+					a.logger.emit(entry)
+					atomic.AddInt64(&a.pending, -1)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// enqueue hands entry to the background drainer, applying the
+// configured OverflowPolicy if the queue is full.
+func (a *asyncLogger) enqueue(entry Entry) {
+	switch a.policy {
+	case OverflowDropNewest:
+		select {
+		case a.queue <- entry:
+			atomic.AddInt64(&a.pending, 1)
+		default:
+		}
+	case OverflowDropAndCount:
+		select {
+		case a.queue <- entry:
+			atomic.AddInt64(&a.pending, 1)
+		default:
+			atomic.AddUint64(&a.dropped, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case a.queue <- entry:
+				atomic.AddInt64(&a.pending, 1)
+				return
+			default:
+				select {
+				case <-a.queue:
+					atomic.AddInt64(&a.pending, -1)
+				default:
+				}
+			}
+		}
+	default: // OverflowBlock
+		a.queue <- entry
+		atomic.AddInt64(&a.pending, 1)
+	}
+}
+
+// drain blocks until every entry handed to enqueue has been passed to
+// logger.emit, or deadline has elapsed (deadline <= 0 means wait
+// forever). It does not stop the background goroutine; callers that
+// also want to shut it down should follow drain with stop.
+func (a *asyncLogger) drain(deadline time.Duration) {
+	var deadlineAt time.Time
+	if deadline > 0 {
+		deadlineAt = time.Now().Add(deadline)
+	}
+	for atomic.LoadInt64(&a.pending) > 0 {
+		if deadline > 0 && time.Now().After(deadlineAt) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// stop signals the background goroutine to drain whatever remains
+// and exit, and waits for it to do so.
+func (a *asyncLogger) stop() {
+	close(a.stopCh)
+	<-a.stoppedCh
+}
+
+// droppedCount returns the number of entries dropped under
+// OverflowDropAndCount.
+func (a *asyncLogger) droppedCount() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+// slowSink is a LogSink whose Write takes long enough that a test can
+// tell whether a caller waited for it to finish, or merely for the
+// entry to be taken off the queue.
+type slowSink struct {
+	delay   time.Duration
+	written int64 // atomic; incremented after Write returns
+}
+
+func (s *slowSink) Write(Entry) error {
+	time.Sleep(s.delay)
+	atomic.AddInt64(&s.written, 1)
+	return nil
+}
+
+func (s *slowSink) Flush() error          { return nil }
+func (s *slowSink) Close() error          { return nil }
+func (s *slowSink) ListFiles() []FileInfo { return nil }
+
+func TestAsyncSecondaryLoggerFlushWaitsForSlowSink(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx := context.Background()
+	slow := &slowSink{delay: 20 * time.Millisecond}
+	l := NewSecondaryLogger(ctx, []LogSink{slow}, "async-slow", true, false, true).
+		WithAsyncBuffer(16, 0, OverflowBlock)
+	defer l.Close()
+
+	l.Logf(ctx, "message")
+
+	if err := l.Flush(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt64(&slow.written); got != 1 {
+		t.Errorf("Flush returned before the slow sink's Write finished: written=%d", got)
+	}
+}
+
+func TestAsyncSecondaryLoggerFlushDrainsQueue(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx := context.Background()
+	mem := &memSink{}
+	l := NewSecondaryLogger(ctx, []LogSink{mem}, "async", true, false, true).
+		WithAsyncBuffer(16, 0, OverflowBlock)
+	defer l.Close()
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		l.Logf(ctx, "message %d", i)
+	}
+
+	if err := l.Flush(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(mem.entries()); got != n {
+		t.Errorf("Flush returned before the queue drained: got %d entries, want %d", got, n)
+	}
+}
+
+func TestAsyncSecondaryLoggerOverflowPolicies(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx := context.Background()
+
+	t.Run("DropAndCount", func(t *testing.T) {
+		mem := &memSink{}
+		l := NewSecondaryLogger(ctx, []LogSink{mem}, "async-dac", true, false, true).
+			WithAsyncBuffer(1, 0, OverflowDropAndCount)
+		defer l.Close()
+
+		for i := 0; i < 50; i++ {
+			l.Logf(ctx, "message %d", i)
+		}
+		if l.AsyncDroppedCount() == 0 {
+			t.Error("expected some messages to be dropped and counted")
+		}
+	})
+}
+
+func benchmarkSecondaryLoggerLogf(b *testing.B, async bool) {
+	s := ScopeWithoutShowLogs(b)
+	defer s.Close(b)
+	setFlags()
+
+	ctx := context.Background()
+	mem := &memSink{}
+	l := NewSecondaryLogger(ctx, []LogSink{mem}, "bench", false, false, false)
+	if async {
+		l = l.WithAsyncBuffer(4096, 0, OverflowDropAndCount)
+	}
+	defer l.Close()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Logf(ctx, "benchmark message")
+		}
+	})
+}
+
+func BenchmarkSecondaryLoggerLogfSync(b *testing.B) {
+	benchmarkSecondaryLoggerLogf(b, false)
+}
+
+func BenchmarkSecondaryLoggerLogfAsync(b *testing.B) {
+	benchmarkSecondaryLoggerLogf(b, true)
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/log/severity"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// loggerT is the common machinery shared by the primary debug/stderr
+// loggers and every SecondaryLogger: it knows how to turn a message
+// and its call site into an Entry and fan it out to all of its
+// sinks. A logger usually has a single fileSink, but SecondaryLogger
+// callers can attach additional sinks (syslog, HTTP, Fluentd, ...)
+// defined in the sink_*.go files.
+type loggerT struct {
+	sinks []LogSink
+}
+
+// getFileSink returns this logger's fileSink, if it has one. It
+// exists mainly for tests, which dig into the underlying file to
+// assert on its contents.
+func (l *loggerT) getFileSink() *fileSink {
+	for _, s := range l.sinks {
+		if fs, ok := s.(*fileSink); ok {
+			return fs
+		}
+	}
+	return nil
+}
+
+func (l *loggerT) listFiles() ([]FileInfo, error) {
+	var out []FileInfo
+	for _, s := range l.sinks {
+		out = append(out, s.ListFiles()...)
+	}
+	return out, nil
+}
+
+// buildEntry captures the call site depth frames above it and
+// renders format/args into an Entry, without doing anything with it.
+// Splitting this out from output lets async logging (see async.go)
+// capture an Entry on the caller's goroutine while deferring the
+// actual sink writes to a background goroutine.
+func (l *loggerT) buildEntry(sev severity.Severity, depth int, tags string, redactable bool, format string, args ...interface{}) Entry {
+	_, file, line, ok := runtime.Caller(depth + 1)
+	if !ok {
+		file = "???"
+		line = 1
+	}
+	return Entry{
+		Severity:   sev,
+		Time:       now(),
+		File:       file,
+		Line:       line,
+		Tags:       tags,
+		Message:    fmt.Sprintf(format, args...),
+		Redactable: redactable,
+	}
+}
+
+// emit writes entry to every sink of this logger.
+func (l *loggerT) emit(entry Entry) {
+	for _, s := range l.sinks {
+		_ = s.Write(entry)
+	}
+}
+
+func (l *loggerT) output(sev severity.Severity, depth int, tags string, redactable bool, format string, args ...interface{}) {
+	l.emit(l.buildEntry(sev, depth+1, tags, redactable, format, args...))
+}
+
+func (l *loggerT) flush() error {
+	var firstErr error
+	for _, s := range l.sinks {
+		if err := s.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (l *loggerT) close() error {
+	var firstErr error
+	for _, s := range l.sinks {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// now is overridden in tests that need deterministic timestamps.
+var now = time.Now
+
+// loggingT holds the global state of the logging package: the
+// configured log directory, the primary sinks, and the registry of
+// active secondary loggers.
+type loggingT struct {
+	mu syncutil.Mutex
+
+	logDir string
+
+	stderrSink struct {
+		threshold severity.Severity
+	}
+
+	secondaryLoggers []*SecondaryLogger
+}
+
+var logging loggingT
+
+var (
+	debugLog  = &loggerT{}
+	stderrLog = &loggerT{}
+)
+
+func init() {
+	setFlags()
+}
+
+// setFlags (re)initializes the primary loggers against the current
+// logging directory. Tests call this after pointing logging.logDir
+// at a scratch directory.
+func setFlags() {
+	debugLog.sinks = []LogSink{NewFileLogSink(&logging.logDir, "cockroach", FormatCrdbV1)}
+	stderrLog.sinks = []LogSink{NewFileLogSink(&logging.logDir, "cockroach-stderr", FormatCrdbV1)}
+}
+
+// Infof logs to the INFO severity of the primary logger.
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	debugLog.output(severity.INFO, 1, tagsFromContext(ctx), false, format, args...)
+}
+
+// tagsFromContext renders the logtags carried by ctx, if any, using
+// the same "k=v,k=v" shape the crdb-v1 formatter expects.
+func tagsFromContext(ctx context.Context) string {
+	return renderLogTags(ctx)
+}
+
+// Flush flushes all active loggers: the primary debug and stderr
+// logs, and every registered secondary logger.
+func Flush() {
+	_ = debugLog.flush()
+	_ = stderrLog.flush()
+
+	logging.mu.Lock()
+	secondaries := append([]*SecondaryLogger(nil), logging.secondaryLoggers...)
+	logging.mu.Unlock()
+
+	for _, sl := range secondaries {
+		_ = sl.Flush(0)
+	}
+}
+
+// SetupRedactionAndStderrRedirects arranges for writes to os.Stderr
+// to be captured into the stderr log file. It returns a cleanup
+// function that undoes the redirect.
+func SetupRedactionAndStderrRedirects() (func(), error) {
+	cleanup, err := redirectStderr(stderrLog)
+	if err != nil {
+		return nil, err
+	}
+	return cleanup, nil
+}
+
+// TestingResetActive clears any state left over from a previous
+// stderr redirect, for use between tests.
+func TestingResetActive() {
+	resetStderrRedirect()
+}
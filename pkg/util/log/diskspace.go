@@ -0,0 +1,16 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+// freeDiskBytes reports the number of bytes free on the filesystem
+// backing dir. It is a package variable so tests can stub it out
+// without needing to actually fill up a disk.
+var freeDiskBytes = statfsFreeBytes
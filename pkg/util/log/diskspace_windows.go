@@ -0,0 +1,38 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+//go:build windows
+
+package log
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func statfsFreeBytes(dir string) (int64, error) {
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	dirPtr, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	k32 := syscall.NewLazyDLL("kernel32.dll")
+	proc := k32.NewProc("GetDiskFreeSpaceExW")
+	_, _, callErr := proc.Call(
+		uintptr(unsafe.Pointer(dirPtr)),
+		uintptr(unsafe.Pointer(&freeBytesAvailable)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFreeBytes)),
+	)
+	if freeBytesAvailable == 0 && callErr != nil && callErr != syscall.Errno(0) {
+		return 0, callErr
+	}
+	return int64(freeBytesAvailable), nil
+}
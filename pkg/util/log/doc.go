@@ -0,0 +1,29 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package log implements CockroachDB's primary (debug/stderr) and
+// secondary logging. The package is layered, and it's worth knowing
+// the layering when reading or extending it:
+//
+//   - clog.go, entry.go, tags.go, severity/, stderr_redirect.go and
+//     test_log_scope.go are the core engine: Entry, the loggerT
+//     shared by every logger, the primary debug/stderr loggers, and
+//     test scaffolding. Everything else builds on this.
+//   - format.go defines Format and the crdb-v1/JSON/logfmt
+//     logFormatter implementations a fileSink renders entries with.
+//   - sink.go, file_sink.go and the sink_*.go files define LogSink
+//     and its implementations (local file, syslog, HTTP, Fluentd);
+//     secondary_log.go's SecondaryLogger fans a single logical log
+//     out over a slice of them.
+//   - ratelimit.go, async.go and retention.go/janitor.go are
+//     orthogonal, opt-in behaviors layered onto SecondaryLogger via
+//     its With* methods: rate limiting, asynchronous buffered
+//     writes, and file retention/GC, respectively.
+package log
@@ -0,0 +1,33 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/log/severity"
+)
+
+// Entry represents a single log message, already decorated with its
+// severity, location and tags, but not yet formatted for any
+// particular sink. It is exported so that LogSink implementations
+// outside this package can be written against it.
+type Entry struct {
+	Severity severity.Severity
+	Time     time.Time
+	File     string
+	Line     int
+	Tags     string
+	Message  string
+	// Redactable is set when Message may contain markers delimiting
+	// sensitive data (see redact.go in the full package).
+	Redactable bool
+}
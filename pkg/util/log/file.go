@@ -0,0 +1,52 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+// FileInfo describes a single rotated log file on disk, as reported
+// by ListLogFiles. It intentionally carries no format-specific
+// information: callers that want to know how a file is encoded can
+// inspect its contents, since ListLogFiles must stay agnostic of the
+// sink (file, syslog, HTTP, ...) and format (crdb-v1, json, logfmt)
+// that produced it.
+type FileInfo struct {
+	Name         string
+	SizeBytes    int64
+	ModTimeNanos int64
+}
+
+// ListLogFiles returns a list of log files, both from the primary
+// logger and from all active secondary loggers, found in the
+// configured log directory(ies).
+func ListLogFiles() ([]FileInfo, error) {
+	var results []FileInfo
+
+	for _, l := range []*loggerT{debugLog, stderrLog} {
+		files, err := l.listFiles()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, files...)
+	}
+
+	logging.mu.Lock()
+	secondaries := append([]*SecondaryLogger(nil), logging.secondaryLoggers...)
+	logging.mu.Unlock()
+
+	for _, sl := range secondaries {
+		files, err := sl.logger.listFiles()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, files...)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/util/log/severity"
+)
+
+// Format identifies how a logger renders an Entry into bytes before
+// they are handed to a sink.
+type Format int
+
+const (
+	// FormatCrdbV1 is the historical human-readable format used by
+	// all CockroachDB logs: "I210101 12:00:00.000000 foo.go:23 [tag] msg".
+	FormatCrdbV1 Format = iota
+	// FormatJSON renders one JSON object per line.
+	FormatJSON
+	// FormatLogfmt renders one logfmt-style (key=value) line per
+	// entry.
+	FormatLogfmt
+)
+
+// String implements fmt.Stringer.
+func (f Format) String() string {
+	switch f {
+	case FormatCrdbV1:
+		return "crdb-v1"
+	case FormatJSON:
+		return "json"
+	case FormatLogfmt:
+		return "logfmt"
+	default:
+		return "unknown"
+	}
+}
+
+// logFormatter turns an Entry into the bytes that get written to a
+// sink. Each Format above has exactly one implementation.
+type logFormatter interface {
+	// formatEntry renders entry into a freshly allocated buffer. The
+	// caller owns the returned buffer.
+	formatEntry(entry Entry) *bytes.Buffer
+}
+
+func formatterForStyle(f Format) logFormatter {
+	switch f {
+	case FormatJSON:
+		return jsonFormatter{}
+	case FormatLogfmt:
+		return logfmtFormatter{}
+	default:
+		return crdbV1Formatter{}
+	}
+}
+
+// crdbV1Formatter reproduces the traditional single-line, severity-
+// tagged text format.
+type crdbV1Formatter struct{}
+
+func (crdbV1Formatter) formatEntry(entry Entry) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	severityChar := byte('I')
+	switch entry.Severity {
+	case severity.WARNING:
+		severityChar = 'W'
+	case severity.ERROR:
+		severityChar = 'E'
+	case severity.FATAL:
+		severityChar = 'F'
+	}
+	fmt.Fprintf(buf, "%c%s %s:%d",
+		severityChar,
+		entry.Time.Format("060102 15:04:05.000000"),
+		entry.File, entry.Line)
+	if entry.Tags != "" {
+		fmt.Fprintf(buf, " [%s]", entry.Tags)
+	}
+	if entry.Redactable {
+		fmt.Fprintf(buf, " %s", redactableMarker(entry.Message))
+	} else {
+		fmt.Fprintf(buf, " %s", entry.Message)
+	}
+	buf.WriteByte('\n')
+	return buf
+}
+
+// jsonFormatter renders one newline-delimited JSON object per entry.
+// Timestamps use RFC3339 with nanosecond precision, so sub-second
+// precision survives serialization; only the wall-clock reading is
+// written, not the monotonic clock reading. logtags are promoted to
+// top-level fields rather than folded into the message.
+type jsonFormatter struct{}
+
+func (jsonFormatter) formatEntry(entry Entry) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	fmt.Fprintf(buf, "%q:%q,", "timestamp", entry.Time.Format(rfc3339NanoWithMonotonic(entry)))
+	fmt.Fprintf(buf, "%q:%q,", "severity", entry.Severity.String())
+	fmt.Fprintf(buf, "%q:%q,", "file", fmt.Sprintf("%s:%d", entry.File, entry.Line))
+	for _, tag := range splitTags(entry.Tags) {
+		fmt.Fprintf(buf, "%q:%q,", tag.key, tag.value)
+	}
+	if entry.Redactable {
+		fmt.Fprintf(buf, "%q:true,", "redactable")
+	}
+	fmt.Fprintf(buf, "%q:%s", "msg", jsonString(entry.Message))
+	buf.WriteByte('}')
+	buf.WriteByte('\n')
+	return buf
+}
+
+// logfmtFormatter renders one logfmt (key=value) line per entry.
+type logfmtFormatter struct{}
+
+func (logfmtFormatter) formatEntry(entry Entry) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "time=%s level=%s file=%s:%d",
+		entry.Time.Format(rfc3339NanoWithMonotonic(entry)),
+		strings.ToLower(entry.Severity.String()),
+		entry.File, entry.Line)
+	for _, tag := range splitTags(entry.Tags) {
+		fmt.Fprintf(buf, " %s=%s", tag.key, logfmtQuote(tag.value))
+	}
+	if entry.Redactable {
+		buf.WriteString(" redactable=true")
+	}
+	fmt.Fprintf(buf, " msg=%s", logfmtQuote(entry.Message))
+	buf.WriteByte('\n')
+	return buf
+}
+
+// rfc3339NanoWithMonotonic returns the layout used to render a
+// timestamp as RFC3339 with nanosecond precision. Time.Format renders
+// only the wall-clock reading: the monotonic clock reading carried by
+// entry.Time (when taken directly from time.Now()) does not appear in
+// the output.
+func rfc3339NanoWithMonotonic(entry Entry) string {
+	return "2006-01-02T15:04:05.000000000Z07:00"
+}
+
+func redactableMarker(msg string) string {
+	return "‹" + msg + "›"
+}
+
+type tagKV struct{ key, value string }
+
+// splitTags turns the "k1=v1,k2=v2" logtags rendering used elsewhere
+// in this package into individual key/value pairs, so that
+// structured formats can promote them to top-level fields instead of
+// folding them into the message text.
+func splitTags(tags string) []tagKV {
+	if tags == "" {
+		return nil
+	}
+	var out []tagKV
+	for _, kv := range strings.Split(tags, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			out = append(out, tagKV{key: parts[0], value: parts[1]})
+		} else {
+			out = append(out, tagKV{key: parts[0], value: ""})
+		}
+	}
+	return out
+}
+
+func jsonString(s string) string {
+	return strconv.Quote(s)
+}
+
+func logfmtQuote(s string) string {
+	if strings.ContainsAny(s, " =\"") {
+		return strconv.Quote(s)
+	}
+	return s
+}
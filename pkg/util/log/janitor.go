@@ -0,0 +1,75 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultJanitorInterval is how often the package-level janitor
+// goroutine re-evaluates every SecondaryLogger's RetentionPolicy.
+const defaultJanitorInterval = time.Minute
+
+var (
+	janitorOnce     sync.Once
+	janitorInterval = defaultJanitorInterval
+)
+
+// startJanitor lazily launches the single background goroutine that
+// enforces every registered SecondaryLogger's retention policy. It is
+// harmless to call repeatedly; only the first call has any effect.
+func startJanitor() {
+	janitorOnce.Do(func() {
+		go runJanitor()
+	})
+}
+
+func runJanitor() {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		enforceAllRetentionPolicies()
+	}
+}
+
+// enforceAllRetentionPolicies runs each registered SecondaryLogger's
+// RetentionPolicy once. It is exported to the package only (not to
+// external callers) so tests can trigger a janitor pass deterministically
+// instead of waiting on the ticker.
+func enforceAllRetentionPolicies() {
+	logging.mu.Lock()
+	secondaries := append([]*SecondaryLogger(nil), logging.secondaryLoggers...)
+	logging.mu.Unlock()
+
+	for _, sl := range secondaries {
+		_, _ = sl.GCNow()
+	}
+}
+
+// GCNow applies this logger's RetentionPolicy (configured via
+// WithRetention) to its own file-backed sinks immediately, without
+// waiting for the janitor's next tick. It is a no-op if
+// WithRetention was never called.
+func (l *SecondaryLogger) GCNow() ([]RemovedFile, error) {
+	if l.retention == nil {
+		return nil, nil
+	}
+	removed, err := gcLogger(l.logger, *l.retention)
+	if err != nil {
+		return removed, err
+	}
+	if len(removed) > 0 {
+		Infof(context.Background(), "log GC removed %d rotated log file(s) for logger %q", len(removed), l.name)
+	}
+	return removed, nil
+}
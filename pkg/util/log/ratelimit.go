@@ -0,0 +1,239 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"container/list"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// defaultSuppressionSummaryEveryN and defaultSuppressionSummaryEvery
+// bound how long a burst of dropped messages can go unreported: a
+// summary line is emitted as soon as either threshold is crossed,
+// whichever comes first.
+const (
+	defaultSuppressionSummaryEveryN = 200
+	defaultSuppressionSummaryEvery  = 5 * time.Second
+
+	// defaultMaxFingerprints bounds the per-fingerprint token bucket
+	// map so that a hot logger with many distinct call sites cannot
+	// grow it without limit; least-recently-used fingerprints are
+	// evicted first.
+	defaultMaxFingerprints = 4096
+)
+
+// rateLimitKeyMode selects how WithRateLimit and
+// WithPerFingerprintRateLimit key their token buckets.
+type rateLimitKeyMode int
+
+const (
+	// rateLimitGlobal gates every message through a single shared
+	// token bucket.
+	rateLimitGlobal rateLimitKeyMode = iota
+	// rateLimitPerFingerprint gates each distinct (format string,
+	// file:line) call site through its own token bucket, so a single
+	// hot call site cannot starve the others.
+	rateLimitPerFingerprint
+)
+
+// tokenBucket is a classic token-bucket rate limiter: tokens accrue
+// at perSec per second, up to burst, and each allowed message
+// consumes one.
+type tokenBucket struct {
+	mu     syncutil.Mutex
+	tokens float64
+	perSec float64
+	burst  float64
+	last   time.Time
+}
+
+func newTokenBucket(perSec float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		tokens: float64(burst),
+		perSec: perSec,
+		burst:  float64(burst),
+		last:   now(),
+	}
+}
+
+// allow reports whether a message may proceed, consuming a token if
+// so.
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := now()
+	b.tokens += n.Sub(b.last).Seconds() * b.perSec
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = n
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// suppressionCounter tracks how many messages a bucket has dropped
+// since the last summary was emitted, and decides when that summary
+// is due.
+type suppressionCounter struct {
+	mu         syncutil.Mutex
+	count      int
+	windowFrom time.Time
+}
+
+// recordDrop increments the counter and reports whether a summary is
+// now due (count reached everyN, or windowFrom is older than every),
+// resetting the window if so. The returned count/duration describe
+// the window that just closed.
+func (c *suppressionCounter) recordDrop(everyN int, every time.Duration) (due bool, count int, dur time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.windowFrom.IsZero() {
+		c.windowFrom = now()
+	}
+	c.count++
+	n := now()
+	if c.count >= everyN || n.Sub(c.windowFrom) >= every {
+		count = c.count
+		dur = n.Sub(c.windowFrom)
+		c.count = 0
+		c.windowFrom = n
+		due = true
+	}
+	return due, count, dur
+}
+
+// fingerprintEntry bundles the per-call-site rate limiter state kept
+// in a fingerprintLimiter.
+type fingerprintEntry struct {
+	key         string
+	bucket      *tokenBucket
+	suppression *suppressionCounter
+}
+
+// fingerprintLimiter is an LRU-bounded set of per-fingerprint token
+// buckets, so a logger that sees unboundedly many distinct call
+// sites (e.g. dynamically generated format strings) cannot grow its
+// rate-limiter state without bound.
+type fingerprintLimiter struct {
+	mu       syncutil.Mutex
+	perSec   float64
+	burst    int
+	capacity int
+	order    *list.List // front = most recently used
+	entries  map[string]*list.Element
+}
+
+func newFingerprintLimiter(capacity int, perSec float64, burst int) *fingerprintLimiter {
+	if capacity <= 0 {
+		capacity = defaultMaxFingerprints
+	}
+	return &fingerprintLimiter{
+		perSec:   perSec,
+		burst:    burst,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (f *fingerprintLimiter) get(key string) *fingerprintEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if el, ok := f.entries[key]; ok {
+		f.order.MoveToFront(el)
+		return el.Value.(*fingerprintEntry)
+	}
+	entry := &fingerprintEntry{
+		key:         key,
+		bucket:      newTokenBucket(f.perSec, f.burst),
+		suppression: &suppressionCounter{},
+	}
+	el := f.order.PushFront(entry)
+	f.entries[key] = el
+	for f.order.Len() > f.capacity {
+		oldest := f.order.Back()
+		if oldest == nil {
+			break
+		}
+		f.order.Remove(oldest)
+		delete(f.entries, oldest.Value.(*fingerprintEntry).key)
+	}
+	return entry
+}
+
+// rateLimiter gates SecondaryLogger.Logf calls through a token
+// bucket, either a single one shared by the whole logger
+// (rateLimitGlobal) or one per distinct call site
+// (rateLimitPerFingerprint). Dropped messages are not silently
+// discarded: a periodic "suppressed N similar messages" line is
+// emitted once enough of them have accumulated, or enough time has
+// passed, whichever comes first.
+type rateLimiter struct {
+	mode rateLimitKeyMode
+
+	global      *tokenBucket
+	suppression *suppressionCounter
+
+	perFingerprint *fingerprintLimiter
+
+	summaryEveryN int
+	summaryEvery  time.Duration
+}
+
+func newGlobalRateLimiter(perSec float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		mode:          rateLimitGlobal,
+		global:        newTokenBucket(perSec, burst),
+		suppression:   &suppressionCounter{},
+		summaryEveryN: defaultSuppressionSummaryEveryN,
+		summaryEvery:  defaultSuppressionSummaryEvery,
+	}
+}
+
+func newPerFingerprintRateLimiter(perSec float64, burst, maxFingerprints int) *rateLimiter {
+	return &rateLimiter{
+		mode:           rateLimitPerFingerprint,
+		perFingerprint: newFingerprintLimiter(maxFingerprints, perSec, burst),
+		summaryEveryN:  defaultSuppressionSummaryEveryN,
+		summaryEvery:   defaultSuppressionSummaryEvery,
+	}
+}
+
+// admit decides whether a message at the given fingerprint (hash of
+// format string + file:line, meaningful only in per-fingerprint mode)
+// may proceed. When it may not, admit reports the summary text to
+// log, if a summary is due, so the caller can emit it through the
+// normal output path.
+func (r *rateLimiter) admit(fingerprint string) (allowed bool, summary string) {
+	var bucket *tokenBucket
+	var suppression *suppressionCounter
+	if r.mode == rateLimitPerFingerprint {
+		entry := r.perFingerprint.get(fingerprint)
+		bucket, suppression = entry.bucket, entry.suppression
+	} else {
+		bucket, suppression = r.global, r.suppression
+	}
+
+	if bucket.allow() {
+		return true, ""
+	}
+
+	if due, count, dur := suppression.recordDrop(r.summaryEveryN, r.summaryEvery); due {
+		summary = fmt.Sprintf("suppressed %d similar messages in the last %s", count, dur.Round(time.Millisecond))
+	}
+	return false, summary
+}
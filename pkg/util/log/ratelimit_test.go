@@ -0,0 +1,93 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestSecondaryLoggerRateLimit(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx := context.Background()
+	mem := &memSink{}
+	l := NewSecondaryLogger(ctx, []LogSink{mem}, "ratelimited", true, false, true).
+		WithRateLimit(0, 5)
+
+	const hammer = 1000
+	for i := 0; i < hammer; i++ {
+		l.Logf(ctx, "message %d", i)
+	}
+
+	entries := mem.entries()
+	var admitted, summaries int
+	for _, e := range entries {
+		if strings.HasPrefix(e.Message, "suppressed ") {
+			summaries++
+			continue
+		}
+		admitted++
+	}
+
+	// With perSec == 0, only the initial burst gets through, and
+	// everything past that is either dropped outright or folded into
+	// a suppression summary.
+	if admitted > 5 {
+		t.Errorf("expected at most the burst size (5) of messages to be admitted, got %d", admitted)
+	}
+	if summaries == 0 {
+		t.Errorf("expected at least one suppression summary line, got none (entries: %+v)", entries)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Message, "suppressed ") && !strings.Contains(e.Message, "similar messages in the last") {
+			t.Errorf("malformed suppression summary: %q", e.Message)
+		}
+	}
+}
+
+func TestSecondaryLoggerPerFingerprintRateLimit(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx := context.Background()
+	mem := &memSink{}
+	l := NewSecondaryLogger(ctx, []LogSink{mem}, "ratelimited-fp", true, false, true).
+		WithPerFingerprintRateLimit(0, 2, 0)
+
+	for i := 0; i < 10; i++ {
+		l.Logf(ctx, "hot call site")
+	}
+	for i := 0; i < 10; i++ {
+		l.Logf(ctx, "a different call site")
+	}
+
+	var admitted int
+	for _, e := range mem.entries() {
+		if !strings.HasPrefix(e.Message, "suppressed ") {
+			admitted++
+		}
+	}
+	// Each of the two distinct fingerprints gets its own burst of 2.
+	if admitted > 4 {
+		t.Errorf("expected at most 4 admitted messages across both fingerprints, got %d", admitted)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy bounds how much rotated log data a single logger
+// (the primary logger, or a SecondaryLogger configured with
+// WithRetention) is allowed to accumulate on disk. A zero value in
+// any field disables that particular constraint.
+type RetentionPolicy struct {
+	// MaxTotalBytes caps the combined size of a logger's rotated
+	// files, not counting the file currently being written to.
+	MaxTotalBytes int64
+	// MaxAge removes rotated files older than this.
+	MaxAge time.Duration
+	// MaxFileCount caps the number of rotated files kept, not
+	// counting the file currently being written to.
+	MaxFileCount int
+	// MinFreeDiskBytes, if set, makes the janitor keep deleting the
+	// oldest rotated files (oldest first) until at least this many
+	// bytes are free on the filesystem backing the log directory, or
+	// it runs out of files it's otherwise allowed to delete.
+	MinFreeDiskBytes int64
+}
+
+// RemovedFile describes a rotated log file deleted by GCLogFiles.
+type RemovedFile struct {
+	Name      string
+	SizeBytes int64
+}
+
+// GCLogFiles applies policy to every file-backed sink known to
+// ListLogFiles -- the primary debug/stderr loggers and every
+// registered SecondaryLogger constructed with enableGc set --
+// deleting whichever rotated files violate it, and returns what was
+// removed. It never removes the file a sink is currently writing to.
+// A SecondaryLogger built with enableGc=false opts out of this sweep
+// entirely; it can still be GC'd on its own schedule via
+// WithRetention.
+func GCLogFiles(policy RetentionPolicy) ([]RemovedFile, error) {
+	var removed []RemovedFile
+
+	for _, l := range []*loggerT{debugLog, stderrLog} {
+		r, err := gcLogger(l, policy)
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, r...)
+	}
+
+	logging.mu.Lock()
+	secondaries := append([]*SecondaryLogger(nil), logging.secondaryLoggers...)
+	logging.mu.Unlock()
+
+	for _, sl := range secondaries {
+		if !sl.enableGc {
+			continue
+		}
+		r, err := gcLogger(sl.logger, policy)
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, r...)
+	}
+
+	if len(removed) > 0 {
+		Infof(context.Background(), "log GC removed %d rotated log file(s)", len(removed))
+	}
+	return removed, nil
+}
+
+func gcLogger(l *loggerT, policy RetentionPolicy) ([]RemovedFile, error) {
+	var removed []RemovedFile
+	for _, s := range l.sinks {
+		fs, ok := s.(*fileSink)
+		if !ok {
+			continue
+		}
+		r, err := fs.gc(policy)
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, r...)
+	}
+	return removed, nil
+}
+
+// gc enforces policy against this sink's rotated files, oldest
+// first, skipping the file currently open for writes, and returns
+// what it removed.
+func (fs *fileSink) gc(policy RetentionPolicy) ([]RemovedFile, error) {
+	if fs.logDir == nil || *fs.logDir == "" {
+		return nil, nil
+	}
+	current := fs.currentFileName()
+
+	candidates := fs.ListFiles()
+	var eligible []FileInfo
+	for _, f := range candidates {
+		if f.Name == current {
+			continue
+		}
+		eligible = append(eligible, f)
+	}
+	sort.Slice(eligible, func(i, j int) bool { return eligible[i].ModTimeNanos < eligible[j].ModTimeNanos })
+
+	toRemove := map[string]FileInfo{}
+
+	if policy.MaxAge > 0 {
+		cutoff := now().Add(-policy.MaxAge)
+		for _, f := range eligible {
+			if time.Unix(0, f.ModTimeNanos).Before(cutoff) {
+				toRemove[f.Name] = f
+			}
+		}
+	}
+
+	remaining := func() []FileInfo {
+		var out []FileInfo
+		for _, f := range eligible {
+			if _, dead := toRemove[f.Name]; !dead {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+
+	if policy.MaxFileCount > 0 {
+		kept := remaining()
+		for len(kept) > policy.MaxFileCount {
+			toRemove[kept[0].Name] = kept[0]
+			kept = kept[1:]
+		}
+	}
+
+	if policy.MaxTotalBytes > 0 {
+		kept := remaining()
+		var total int64
+		for _, f := range kept {
+			total += f.SizeBytes
+		}
+		for len(kept) > 0 && total > policy.MaxTotalBytes {
+			total -= kept[0].SizeBytes
+			toRemove[kept[0].Name] = kept[0]
+			kept = kept[1:]
+		}
+	}
+
+	if policy.MinFreeDiskBytes > 0 {
+		free, err := freeDiskBytes(*fs.logDir)
+		if err == nil {
+			var freed int64
+			kept := remaining()
+			for len(kept) > 0 && free+freed < policy.MinFreeDiskBytes {
+				freed += kept[0].SizeBytes
+				toRemove[kept[0].Name] = kept[0]
+				kept = kept[1:]
+			}
+		}
+	}
+
+	var removed []RemovedFile
+	for _, f := range toRemove {
+		if err := os.Remove(filepath.Join(*fs.logDir, f.Name)); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, err
+		}
+		removed = append(removed, RemovedFile{Name: f.Name, SizeBytes: f.SizeBytes})
+	}
+	return removed, nil
+}
+
+// currentFileName returns the base name of the file this sink is
+// currently writing to, or "" if it hasn't created one yet.
+func (fs *fileSink) currentFileName() string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.mu.file == nil {
+		return ""
+	}
+	if sb, ok := fs.mu.file.(*syncBuffer); ok {
+		return filepath.Base(sb.file.Name())
+	}
+	return ""
+}
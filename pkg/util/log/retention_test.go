@@ -0,0 +1,273 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+// writeFakeRotatedFile creates a fake rotated log file called
+// prefix.suffix.log in dir, sized n bytes, with its mtime set to age
+// in the past.
+func writeFakeRotatedFile(t *testing.T, dir, prefix, suffix string, n int, age time.Duration) string {
+	t.Helper()
+	name := prefix + "." + suffix + ".log"
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, make([]byte, n), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestFileSinkGCMaxFileCount(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, err := ioutil.TempDir("", "retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	oldest := writeFakeRotatedFile(t, dir, "woo", "1", 10, 3*time.Hour)
+	middle := writeFakeRotatedFile(t, dir, "woo", "2", 10, 2*time.Hour)
+	newest := writeFakeRotatedFile(t, dir, "woo", "3", 10, 1*time.Hour)
+
+	fs := newFileSink(&dir, "woo", FormatCrdbV1)
+	// Pretend this file is the one currently being written to, so it
+	// must never be GC'd regardless of age or count.
+	current, err := os.OpenFile(filepath.Join(dir, "woo.current.log"), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.mu.file = &syncBuffer{file: current}
+
+	removed, err := fs.gc(RetentionPolicy{MaxFileCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var removedNames []string
+	for _, r := range removed {
+		removedNames = append(removedNames, r.Name)
+	}
+	sort.Strings(removedNames)
+	if want := []string{oldest, middle}; !namesEqual(removedNames, want) {
+		t.Errorf("got removed=%v, want %v", removedNames, want)
+	}
+
+	remaining, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remainingNames []string
+	for _, f := range remaining {
+		remainingNames = append(remainingNames, f.Name())
+	}
+	sort.Strings(remainingNames)
+	if want := []string{"woo.3.log", "woo.current.log"}; !namesEqual(remainingNames, want) {
+		t.Errorf("got remaining=%v, want %v", remainingNames, want)
+	}
+	_ = newest
+}
+
+func TestFileSinkGCMaxAge(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, err := ioutil.TempDir("", "retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	old := writeFakeRotatedFile(t, dir, "woo", "1", 10, 2*time.Hour)
+	recent := writeFakeRotatedFile(t, dir, "woo", "2", 10, time.Minute)
+
+	fs := newFileSink(&dir, "woo", FormatCrdbV1)
+
+	removed, err := fs.gc(RetentionPolicy{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Name != old {
+		t.Errorf("got removed=%+v, want just %q", removed, old)
+	}
+	if _, err := os.Stat(filepath.Join(dir, recent)); err != nil {
+		t.Errorf("recent file was removed: %v", err)
+	}
+}
+
+func TestFileSinkGCMaxTotalBytes(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, err := ioutil.TempDir("", "retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	oldest := writeFakeRotatedFile(t, dir, "woo", "1", 100, 3*time.Hour)
+	newest := writeFakeRotatedFile(t, dir, "woo", "2", 100, time.Hour)
+
+	fs := newFileSink(&dir, "woo", FormatCrdbV1)
+
+	removed, err := fs.gc(RetentionPolicy{MaxTotalBytes: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Name != oldest {
+		t.Errorf("got removed=%+v, want just %q", removed, oldest)
+	}
+	if _, err := os.Stat(filepath.Join(dir, newest)); err != nil {
+		t.Errorf("newest file was removed: %v", err)
+	}
+}
+
+func TestFileSinkGCMinFreeDiskBytes(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, err := ioutil.TempDir("", "retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	oldest := writeFakeRotatedFile(t, dir, "woo", "1", 10, 2*time.Hour)
+	_ = writeFakeRotatedFile(t, dir, "woo", "2", 10, time.Hour)
+
+	fs := newFileSink(&dir, "woo", FormatCrdbV1)
+
+	// Stub freeDiskBytes to report the disk has no free space at all.
+	// gc must reach the threshold by tallying the size of the files
+	// it has decided to remove (10 bytes, the oldest file), not by
+	// re-querying the real disk once something has actually been
+	// unlinked -- nothing has, since os.Remove only runs after the
+	// marking loop below. It should therefore be called exactly once.
+	defer func(orig func(string) (int64, error)) { freeDiskBytes = orig }(freeDiskBytes)
+	calls := 0
+	freeDiskBytes = func(string) (int64, error) {
+		calls++
+		return 0, nil
+	}
+
+	removed, err := fs.gc(RetentionPolicy{MinFreeDiskBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Name != oldest {
+		t.Errorf("got removed=%+v, want just %q", removed, oldest)
+	}
+	if calls != 1 {
+		t.Errorf("freeDiskBytes called %d times, want exactly 1", calls)
+	}
+}
+
+func TestSecondaryLoggerGCNow(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l := NewSecondaryLogger(ctx, nil, "gctest", true, false, false).
+		WithRetention(RetentionPolicy{MaxAge: 30 * time.Minute})
+	defer l.Close()
+
+	// A logger with no retention configured is a no-op.
+	l2 := NewSecondaryLogger(ctx, nil, "gctest-none", true, false, false)
+	if removed, err := l2.GCNow(); err != nil || removed != nil {
+		t.Errorf("expected no-op GCNow, got removed=%+v err=%v", removed, err)
+	}
+
+	dir := l.logger.getFileSink().logDir
+	oldest := writeFakeRotatedFile(t, *dir, "gctest", "1", 10, time.Hour)
+
+	l.Logf(ctx, "keep me current")
+	Flush()
+
+	removed, err := l.GCNow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Name != oldest {
+		t.Errorf("got removed=%+v, want just %q", removed, oldest)
+	}
+}
+
+// TestGCLogFilesRespectsEnableGc verifies that a SecondaryLogger
+// constructed with enableGc=false keeps its rotated files across a
+// GCLogFiles sweep, even though the policy passed in would otherwise
+// remove them, while a logger constructed with enableGc=true does
+// not.
+func TestGCLogFilesRespectsEnableGc(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gc := NewSecondaryLogger(ctx, nil, "gc-enabled", true, false, false)
+	defer gc.Close()
+	noGc := NewSecondaryLogger(ctx, nil, "gc-disabled", false, false, false)
+	defer noGc.Close()
+
+	gc.Logf(ctx, "keep me current")
+	noGc.Logf(ctx, "keep me current")
+	Flush()
+
+	gcDir := gc.logger.getFileSink().logDir
+	gcOld := writeFakeRotatedFile(t, *gcDir, "gc-enabled", "1", 10, time.Hour)
+	noGcDir := noGc.logger.getFileSink().logDir
+	noGcOld := writeFakeRotatedFile(t, *noGcDir, "gc-disabled", "1", 10, time.Hour)
+
+	removed, err := GCLogFiles(RetentionPolicy{MaxAge: 30 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var removedNames []string
+	for _, r := range removed {
+		removedNames = append(removedNames, r.Name)
+	}
+	if !namesEqual(removedNames, []string{gcOld}) {
+		t.Errorf("got removed=%v, want just %q", removedNames, gcOld)
+	}
+	if _, err := os.Stat(filepath.Join(*noGcDir, noGcOld)); err != nil {
+		t.Errorf("enableGc=false logger's rotated file was removed: %v", err)
+	}
+}
+
+func namesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,270 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/log/severity"
+)
+
+// SecondaryLogger represents a secondary log file, used by
+// subsystems (SQL audit log, exec log, slow query log, ...) that
+// want their own rotated log files instead of spilling into the
+// primary debug log.
+type SecondaryLogger struct {
+	logger *loggerT
+	name   string
+
+	// enableGc gates this logger's participation in GCLogFiles: a
+	// logger constructed with enableGc=false keeps its rotated files
+	// even when another component calls GCLogFiles against them (it
+	// can still be GC'd via its own WithRetention policy).
+	enableGc bool
+	// forceSyncWrites makes every Logf call block until its entry has
+	// been fsynced to every sink, instead of returning once it has
+	// merely been written. It is for loggers where durability matters
+	// more than latency (e.g. an audit log). It has no effect once
+	// WithAsyncBuffer is in use: Logf then returns as soon as the
+	// entry is queued.
+	forceSyncWrites bool
+	enableMsgCount  bool
+	// msgCount is only maintained when enableMsgCount is set; see
+	// MsgCount. It's written from Logf's caller goroutine, which may
+	// be called concurrently, so it's updated atomically.
+	msgCount uint64
+
+	// limiter is nil unless WithRateLimit or
+	// WithPerFingerprintRateLimit was called; see ratelimit.go.
+	limiter *rateLimiter
+
+	// async is nil unless WithAsyncBuffer was called; see async.go.
+	async *asyncLogger
+
+	// retention is nil unless WithRetention was called; see
+	// retention.go and janitor.go.
+	retention *RetentionPolicy
+
+	// redactable is set by WithRedaction; see that method.
+	redactable bool
+}
+
+// NewSecondaryLogger creates a new secondary logger which fans its
+// output out to sinks. If sinks is empty, it defaults to a single
+// file sink rendering FormatCrdbV1 in the primary log directory,
+// named with fileNamePrefix, which matches the historical behavior
+// of this function. Passing, say, []LogSink{NewFileLogSink(...),
+// NewSyslogSink(...)} makes a single logical logger (e.g. the SQL
+// audit log) write to both a local file and a syslog collector.
+//
+// enableGc controls whether this logger's rotated files are swept by
+// a package-wide GCLogFiles call (a logger can still configure its
+// own independent schedule via WithRetention regardless of this
+// flag). forceSyncWrites makes every Logf block until its entry is
+// fsynced to every sink, trading latency for durability; it is
+// ignored in asynchronous mode (see WithAsyncBuffer). enableMsgCount
+// turns on the counter read back by MsgCount.
+func NewSecondaryLogger(
+	ctx context.Context,
+	sinks []LogSink,
+	fileNamePrefix string,
+	enableGc bool,
+	forceSyncWrites bool,
+	enableMsgCount bool,
+) *SecondaryLogger {
+	if len(sinks) == 0 {
+		sinks = []LogSink{NewFileLogSink(&logging.logDir, fileNamePrefix, FormatCrdbV1)}
+	}
+	l := &SecondaryLogger{
+		logger:          &loggerT{sinks: sinks},
+		name:            fileNamePrefix,
+		enableGc:        enableGc,
+		forceSyncWrites: forceSyncWrites,
+		enableMsgCount:  enableMsgCount,
+	}
+
+	logging.mu.Lock()
+	logging.secondaryLoggers = append(logging.secondaryLoggers, l)
+	logging.mu.Unlock()
+
+	return l
+}
+
+// WithRateLimit gates this logger's Logf calls through a single
+// token bucket shared by all call sites: at most perSec messages per
+// second are let through on average, with bursts of up to burst
+// messages. Messages dropped by the limiter are not silently lost: a
+// "suppressed N similar messages in the last T" line is emitted once
+// enough have accumulated (or enough time has passed). It returns
+// the receiver so it can be chained onto NewSecondaryLogger.
+//
+// This matters for hot secondary logs (the SQL audit log, the
+// slow-query log) that can otherwise fill disk during an incident.
+func (l *SecondaryLogger) WithRateLimit(perSec float64, burst int) *SecondaryLogger {
+	l.limiter = newGlobalRateLimiter(perSec, burst)
+	return l
+}
+
+// WithPerFingerprintRateLimit is like WithRateLimit, but keys the
+// token bucket per distinct call site (a hash of the format string
+// and file:line) instead of sharing one bucket across the whole
+// logger, so a single noisy call site cannot starve the others. The
+// set of tracked call sites is LRU-bounded by maxFingerprints (0
+// picks a sensible default) to keep memory use bounded even if the
+// logger is invoked from unboundedly many call sites.
+func (l *SecondaryLogger) WithPerFingerprintRateLimit(perSec float64, burst, maxFingerprints int) *SecondaryLogger {
+	l.limiter = newPerFingerprintRateLimiter(perSec, burst, maxFingerprints)
+	return l
+}
+
+// WithAsyncBuffer switches this logger to asynchronous mode: Logf
+// enqueues a formatted Entry into a bounded ring buffer of queueSize
+// entries instead of writing to the sinks on the caller's own
+// goroutine, and a background goroutine drains the buffer, flushing
+// the sinks every flushInterval (0 disables the periodic flush,
+// relying on Close/Flush to drain). policy selects what happens when
+// producers outrun the drainer. It returns the receiver so it can be
+// chained onto NewSecondaryLogger.
+//
+// This exists because the synchronous path holds the caller's
+// goroutine on disk I/O; hot call sites that can't afford to block
+// on that should use this instead.
+func (l *SecondaryLogger) WithAsyncBuffer(queueSize int, flushInterval time.Duration, policy OverflowPolicy) *SecondaryLogger {
+	l.async = newAsyncLogger(l.logger, queueSize, flushInterval, policy)
+	return l
+}
+
+// AsyncDroppedCount returns the number of entries dropped by the
+// async buffer under OverflowDropAndCount. It is always zero unless
+// WithAsyncBuffer(..., OverflowDropAndCount) was used.
+func (l *SecondaryLogger) AsyncDroppedCount() uint64 {
+	if l.async == nil {
+		return 0
+	}
+	return l.async.droppedCount()
+}
+
+// WithRetention bounds how much rotated log data this logger is
+// allowed to accumulate on disk: it registers policy and starts the
+// package-level janitor goroutine (if not already running), which
+// will periodically call GCNow on this logger among all others that
+// have a retention policy configured. It returns the receiver so it
+// can be chained onto NewSecondaryLogger.
+func (l *SecondaryLogger) WithRetention(policy RetentionPolicy) *SecondaryLogger {
+	l.retention = &policy
+	startJanitor()
+	return l
+}
+
+// WithRedaction marks every Entry this logger produces as
+// redactable: FormatCrdbV1 (and the syslog sink) render its message
+// wrapped in redaction markers (see redactableMarker in format.go),
+// while FormatJSON, FormatLogfmt and the HTTP and Fluentd sinks leave
+// the message text as is and add a redactable=true field, e.g.
+// `{"redactable":true,...}` for FormatJSON. Callers that
+// enable this are asserting that every format string logged through
+// this logger already delimits sensitive arguments the way the
+// downstream redaction tooling expects; this package does not itself
+// do any redaction, only preserves the marker. It returns the
+// receiver so it can be chained onto NewSecondaryLogger.
+func (l *SecondaryLogger) WithRedaction() *SecondaryLogger {
+	l.redactable = true
+	return l
+}
+
+// Logf logs to this secondary logger at INFO severity.
+func (l *SecondaryLogger) Logf(ctx context.Context, format string, args ...interface{}) {
+	l.logDepth(ctx, 1, severity.INFO, format, args...)
+}
+
+// logDepth is the common entry point used by Logf and by callers
+// that want to report a different call site (depth) or severity.
+func (l *SecondaryLogger) logDepth(
+	ctx context.Context, depth int, sev severity.Severity, format string, args ...interface{},
+) {
+	if l.limiter != nil {
+		_, file, line, ok := runtime.Caller(depth + 1)
+		if !ok {
+			file, line = "???", 1
+		}
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s:%d:%s", file, line, format)
+		fingerprint := strconv.FormatUint(h.Sum64(), 16)
+		allowed, summary := l.limiter.admit(fingerprint)
+		if summary != "" {
+			// The summary line itself is never redactable: it's
+			// synthesized by the limiter, not passed through from a
+			// caller-supplied format string.
+			l.record(severity.INFO, depth+1, renderLogTags(ctx), false, "%s", summary)
+		}
+		if !allowed {
+			return
+		}
+	}
+
+	if l.enableMsgCount {
+		atomic.AddUint64(&l.msgCount, 1)
+	}
+	l.record(sev, depth+1, renderLogTags(ctx), l.redactable, format, args...)
+}
+
+// MsgCount returns the number of messages logged through this logger
+// via Logf/logDepth so far. It is always zero unless the logger was
+// constructed with enableMsgCount set.
+func (l *SecondaryLogger) MsgCount() uint64 {
+	return atomic.LoadUint64(&l.msgCount)
+}
+
+// record builds an Entry for format/args at depth frames above its
+// caller, and either writes it to the sinks directly or, in
+// asynchronous mode, enqueues it for the background drainer. In
+// synchronous mode, if forceSyncWrites is set, it blocks until the
+// entry has been fsynced to every sink before returning.
+func (l *SecondaryLogger) record(
+	sev severity.Severity, depth int, tags string, redactable bool, format string, args ...interface{},
+) {
+	entry := l.logger.buildEntry(sev, depth+1, tags, redactable, format, args...)
+	if l.async != nil {
+		l.async.enqueue(entry)
+		return
+	}
+	l.logger.emit(entry)
+	if l.forceSyncWrites {
+		_ = l.logger.flush()
+	}
+}
+
+// Flush flushes this logger's sinks, waiting up to deadline (0 means
+// wait forever) for any asynchronously buffered entries to drain
+// first.
+func (l *SecondaryLogger) Flush(deadline time.Duration) error {
+	if l.async != nil {
+		l.async.drain(deadline)
+	}
+	return l.logger.flush()
+}
+
+// Close flushes and releases the resources held by this secondary
+// logger, draining any asynchronously buffered entries first.
+func (l *SecondaryLogger) Close() {
+	if l.async != nil {
+		l.async.drain(defaultAsyncDrainDeadline)
+		l.async.stop()
+	}
+	_ = l.logger.flush()
+	_ = l.logger.close()
+}
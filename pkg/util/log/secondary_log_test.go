@@ -21,10 +21,19 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log/severity"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/logtags"
 )
 
 func TestSecondaryLog(t *testing.T) {
+	for _, format := range []Format{FormatCrdbV1, FormatJSON, FormatLogfmt} {
+		t.Run(format.String(), func(t *testing.T) {
+			testSecondaryLog(t, format)
+		})
+	}
+}
+
+func testSecondaryLog(t *testing.T, format Format) {
 	defer leaktest.AfterTest(t)()
 
 	s := ScopeWithoutShowLogs(t)
@@ -35,7 +44,7 @@ func TestSecondaryLog(t *testing.T) {
 	defer cancel()
 
 	// Make a new logger, in the same directory.
-	l := NewSecondaryLogger(ctx, &logging.logDir, "woo", true, false, true)
+	l := NewSecondaryLogger(ctx, []LogSink{NewFileLogSink(&logging.logDir, "woo", format)}, "woo", true, false, true)
 	defer l.Close()
 
 	// Interleave some messages.
@@ -75,6 +84,114 @@ func TestSecondaryLog(t *testing.T) {
 
 }
 
+func TestSecondaryLoggerMsgCount(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := &memSink{}
+	counted := NewSecondaryLogger(ctx, []LogSink{mem}, "woo-count", true, false, true)
+	defer counted.Close()
+
+	uncounted := NewSecondaryLogger(ctx, []LogSink{&memSink{}}, "woo-nocount", true, false, false)
+	defer uncounted.Close()
+
+	for i := 0; i < 3; i++ {
+		counted.Logf(ctx, "msg %d", i)
+		uncounted.Logf(ctx, "msg %d", i)
+	}
+
+	if got := counted.MsgCount(); got != 3 {
+		t.Errorf("MsgCount() = %d, want 3", got)
+	}
+	if got := uncounted.MsgCount(); got != 0 {
+		t.Errorf("MsgCount() on a logger built with enableMsgCount=false = %d, want 0", got)
+	}
+}
+
+func TestSecondaryLoggerForceSyncWrites(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := &memSink{}
+	l := NewSecondaryLogger(ctx, []LogSink{mem}, "woo-sync", true, true, false)
+	defer l.Close()
+
+	l.Logf(ctx, "durable message")
+
+	// forceSyncWrites should have flushed the sink by the time Logf
+	// returns, with no separate Flush() call needed.
+	if got := mem.flushCount(); got != 1 {
+		t.Errorf("sink flushed %d times after one Logf with forceSyncWrites, want 1", got)
+	}
+
+	mem2 := &memSink{}
+	lNoSync := NewSecondaryLogger(ctx, []LogSink{mem2}, "woo-nosync", true, false, false)
+	defer lNoSync.Close()
+
+	lNoSync.Logf(ctx, "non-durable message")
+	if got := mem2.flushCount(); got != 0 {
+		t.Errorf("sink flushed %d times after one Logf without forceSyncWrites, want 0", got)
+	}
+}
+
+func TestSecondaryLoggerWithRedaction(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mem := &memSink{}
+	l := NewSecondaryLogger(ctx, []LogSink{mem}, "woo-redact", true, false, false).WithRedaction()
+	defer l.Close()
+
+	l.Logf(ctx, "secret %s", "value")
+	Flush()
+
+	entries := mem.entries()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if !entries[0].Redactable {
+		t.Errorf("entry from a WithRedaction logger has Redactable=false: %+v", entries[0])
+	}
+
+	// Check that every formatter actually surfaces the redaction bit
+	// to a downstream collector: crdb-v1 wraps the message itself in
+	// markers, while JSON and logfmt instead flag it alongside the
+	// untouched message.
+	rendered := crdbV1Formatter{}.formatEntry(entries[0]).String()
+	wantMarker := redactableMarker(entries[0].Message)
+	if !strings.Contains(rendered, wantMarker) {
+		t.Errorf("crdb-v1: rendered entry missing redaction marker %q:\n%s", wantMarker, rendered)
+	}
+
+	for format, want := range map[Format]string{
+		FormatJSON:   `"redactable":true,`,
+		FormatLogfmt: " redactable=true",
+	} {
+		rendered := formatterForStyle(format).formatEntry(entries[0]).String()
+		if !strings.Contains(rendered, want) {
+			t.Errorf("%s: rendered entry missing %q:\n%s", format, want, rendered)
+		}
+	}
+}
+
 func TestRedirectStderrWithSecondaryLoggersActive(t *testing.T) {
 	s := ScopeWithoutShowLogs(t)
 	defer s.Close(t)
@@ -90,14 +207,21 @@ func TestRedirectStderrWithSecondaryLoggersActive(t *testing.T) {
 	}
 	defer cleanup()
 
-	// Now create a secondary logger in the same directory.
+	// Now create a secondary logger in the same directory, and a
+	// second one backed only by a non-file sink, to make sure the
+	// stderr redirect doesn't leak into either kind of sink.
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	l := NewSecondaryLogger(ctx, &logging.logDir, "woo", true, false, true)
+	l := NewSecondaryLogger(ctx, []LogSink{NewFileLogSink(&logging.logDir, "woo", FormatCrdbV1)}, "woo", true, false, true)
 	defer l.Close()
 
-	// Log something on the secondary logger.
+	mem := &memSink{}
+	lMem := NewSecondaryLogger(ctx, []LogSink{mem}, "woo-mem", true, false, true)
+	defer lMem.Close()
+
+	// Log something on both secondary loggers.
 	l.Logf(context.Background(), "test456")
+	lMem.Logf(context.Background(), "test456")
 
 	// Send something on stderr.
 	const stderrText = "hello stderr"
@@ -120,6 +244,53 @@ func TestRedirectStderrWithSecondaryLoggersActive(t *testing.T) {
 	if strings.Contains(string(contents2), stderrText) {
 		t.Errorf("secondary log erronously contains stderr text\n%s", contents2)
 	}
+
+	// Check the non-file secondary sink: it shouldn't see the stderr
+	// text either.
+	for _, e := range mem.entries() {
+		if strings.Contains(e.Message, stderrText) {
+			t.Errorf("non-file secondary sink erroneously contains stderr text: %+v", e)
+		}
+	}
+}
+
+// memSink is a minimal in-memory LogSink used to verify that
+// non-file sinks are wired up the same way file sinks are, without
+// touching disk.
+type memSink struct {
+	mu struct {
+		syncutil.Mutex
+		got     []Entry
+		flushes int
+	}
+}
+
+func (m *memSink) Write(entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mu.got = append(m.mu.got, entry)
+	return nil
+}
+
+func (m *memSink) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mu.flushes++
+	return nil
+}
+func (m *memSink) Close() error          { return nil }
+func (m *memSink) ListFiles() []FileInfo { return nil }
+
+func (m *memSink) flushCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mu.flushes
+}
+
+func (m *memSink) entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Entry(nil), m.mu.got...)
 }
 
 func TestListLogFilesIncludeSecondaryLogs(t *testing.T) {
@@ -131,7 +302,7 @@ func TestListLogFilesIncludeSecondaryLogs(t *testing.T) {
 	defer cancel()
 
 	// Make a new logger, in the same directory.
-	l := NewSecondaryLogger(ctx, &logging.logDir, "woo", true, false, true)
+	l := NewSecondaryLogger(ctx, []LogSink{NewFileLogSink(&logging.logDir, "woo", FormatCrdbV1)}, "woo", true, false, true)
 	defer l.Close()
 
 	// Emit some logging and ensure the files gets created.
@@ -155,3 +326,33 @@ func TestListLogFilesIncludeSecondaryLogs(t *testing.T) {
 		t.Fatalf("unexpected results; expected file %q, got: %+v", expectedName, results)
 	}
 }
+
+// TestListFilesExcludesOverlappingPrefix guards against a sink whose
+// fileNamePrefix is itself a string prefix of another sink's
+// (e.g. "cockroach" and "cockroach-stderr") picking up that other
+// sink's files in its own ListFiles.
+func TestListFilesExcludesOverlappingPrefix(t *testing.T) {
+	s := ScopeWithoutShowLogs(t)
+	defer s.Close(t)
+	setFlags()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	short := NewSecondaryLogger(ctx, []LogSink{NewFileLogSink(&logging.logDir, "woo", FormatCrdbV1)}, "woo", true, false, true)
+	defer short.Close()
+	long := NewSecondaryLogger(ctx, []LogSink{NewFileLogSink(&logging.logDir, "woo-extended", FormatCrdbV1)}, "woo-extended", true, false, true)
+	defer long.Close()
+
+	short.Logf(ctx, "short")
+	long.Logf(ctx, "long")
+	Flush()
+
+	longName := filepath.Base(long.logger.getFileSink().mu.file.(*syncBuffer).file.Name())
+
+	for _, f := range short.logger.getFileSink().ListFiles() {
+		if f.Name == longName {
+			t.Fatalf("%q sink's ListFiles picked up %q, which belongs to the %q sink", "woo", longName, "woo-extended")
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package severity defines the severity levels used throughout the
+// logging package. It is kept separate from package log so that it
+// can be imported by packages that log package itself depends on,
+// without incurring a dependency cycle.
+package severity
+
+// Severity identifies the sort of log: info, warning, error, etc.
+type Severity int32
+
+// This is a copy of the severity levels defined in the logpb proto,
+// kept here so that low-level packages do not need to import the
+// generated proto code.
+const (
+	UNKNOWN Severity = iota
+	INFO
+	WARNING
+	ERROR
+	FATAL
+	// NONE is used to disable a sink entirely: no message is ever
+	// considered at or above this severity.
+	NONE
+)
+
+var severityName = map[Severity]string{
+	UNKNOWN: "UNKNOWN",
+	INFO:    "INFO",
+	WARNING: "WARNING",
+	ERROR:   "ERROR",
+	FATAL:   "FATAL",
+	NONE:    "NONE",
+}
+
+// String implements fmt.Stringer.
+func (s Severity) String() string {
+	if name, ok := severityName[s]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+// LogSink is the destination end of a logger: something that knows
+// how to durably record Entry values. File-based logging (fileSink)
+// is the original and still most common implementation; syslogSink,
+// httpSink and fluentSink let a SecondaryLogger fan its output out to
+// external collectors instead of, or in addition to, local disk.
+type LogSink interface {
+	// Write records entry. Implementations may buffer it until the
+	// next Flush, and are responsible for their own serialization
+	// format.
+	Write(entry Entry) error
+	// Flush forces any buffered entries out.
+	Flush() error
+	// Close releases the resources held by the sink.
+	Close() error
+	// ListFiles returns the rotated files, if any, backing this
+	// sink. Sinks with no on-disk footprint (syslog, HTTP, Fluentd)
+	// return nil.
+	ListFiles() []FileInfo
+}
+
+// NewFileLogSink creates a LogSink that appends formatted entries to
+// a file named after fileNamePrefix in dirName, rendered using
+// format. The sink does not rotate that file.
+func NewFileLogSink(dirName *string, fileNamePrefix string, format Format) LogSink {
+	return newFileSink(dirName, fileNamePrefix, format)
+}
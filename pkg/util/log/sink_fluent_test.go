@@ -0,0 +1,225 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log/severity"
+)
+
+// decodeFluentString decodes a MessagePack string (fixstr or str32,
+// the two encodings writeFluentString produces) at the start of buf,
+// returning the decoded value and the number of bytes consumed.
+func decodeFluentString(t *testing.T, buf []byte) (string, int) {
+	t.Helper()
+	if len(buf) == 0 {
+		t.Fatal("decodeFluentString: empty buffer")
+	}
+	switch {
+	case buf[0]&0xe0 == 0xa0:
+		n := int(buf[0] & 0x1f)
+		return string(buf[1 : 1+n]), 1 + n
+	case buf[0] == 0xdb:
+		n := int(buf[1])<<24 | int(buf[2])<<16 | int(buf[3])<<8 | int(buf[4])
+		return string(buf[5 : 5+n]), 5 + n
+	default:
+		t.Fatalf("decodeFluentString: unexpected leading byte 0x%x", buf[0])
+		return "", 0
+	}
+}
+
+// decodeFluentUint decodes the uint64 encoding writeFluentUint
+// produces (always the 9-byte 0xcf form).
+func decodeFluentUint(t *testing.T, buf []byte) (uint64, int) {
+	t.Helper()
+	if len(buf) < 9 || buf[0] != 0xcf {
+		t.Fatalf("decodeFluentUint: not a uint64 encoding: %x", buf)
+	}
+	var v uint64
+	for i := 1; i <= 8; i++ {
+		v = v<<8 | uint64(buf[i])
+	}
+	return v, 9
+}
+
+func TestFluentSinkEncodesMessagePackMessage(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgCh := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		msgCh <- append([]byte(nil), buf[:n]...)
+	}()
+
+	sink := NewFluentSink(ln.Addr().String(), "cockroach.secondary")
+	defer sink.Close()
+
+	entryTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := Entry{
+		Severity: severity.ERROR,
+		Time:     entryTime,
+		File:     "foo.go",
+		Line:     42,
+		Tags:     "n=1",
+		Message:  "boom",
+	}
+	if err := sink.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+	select {
+	case got = <-msgCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the fluent connection to receive a message")
+	}
+
+	if len(got) == 0 || got[0] != 0x93 {
+		t.Fatalf("message does not start with a 3-element fixarray header: %x", got)
+	}
+	off := 1
+
+	tag, n := decodeFluentString(t, got[off:])
+	off += n
+	if tag != "cockroach.secondary" {
+		t.Errorf("tag = %q, want %q", tag, "cockroach.secondary")
+	}
+
+	ts, n := decodeFluentUint(t, got[off:])
+	off += n
+	if int64(ts) != entryTime.Unix() {
+		t.Errorf("timestamp = %d, want %d", ts, entryTime.Unix())
+	}
+
+	if off >= len(got) || got[off]&0xf0 != 0x80 {
+		t.Fatalf("record does not start with a fixmap header: %x", got[off:])
+	}
+	fieldCount := int(got[off] & 0x0f)
+	off++
+
+	record := map[string]string{}
+	for i := 0; i < fieldCount; i++ {
+		var k, v string
+		k, n = decodeFluentString(t, got[off:])
+		off += n
+		v, n = decodeFluentString(t, got[off:])
+		off += n
+		record[k] = v
+	}
+
+	want := map[string]string{
+		"severity": "ERROR",
+		"file":     fmt.Sprintf("%s:%d", entry.File, entry.Line),
+		"tags":     "n=1",
+		"message":  "boom",
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("record[%q] = %q, want %q", k, record[k], v)
+		}
+	}
+	if off != len(got) {
+		t.Errorf("decoded %d of %d bytes, %d left over", off, len(got), len(got)-off)
+	}
+}
+
+func TestFluentSinkRedactable(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgCh := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		msgCh <- append([]byte(nil), buf[:n]...)
+	}()
+
+	sink := NewFluentSink(ln.Addr().String(), "cockroach.secondary")
+	defer sink.Close()
+
+	entry := Entry{
+		Severity:   severity.ERROR,
+		Time:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		File:       "foo.go",
+		Line:       42,
+		Message:    "secret",
+		Redactable: true,
+	}
+	if err := sink.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+	select {
+	case got = <-msgCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the fluent connection to receive a message")
+	}
+
+	// Skip past the fixarray header, tag and timestamp to the record.
+	off := 1
+	_, n := decodeFluentString(t, got[off:])
+	off += n
+	_, n = decodeFluentUint(t, got[off:])
+	off += n
+
+	fieldCount := int(got[off] & 0x0f)
+	off++
+	record := map[string]string{}
+	for i := 0; i < fieldCount; i++ {
+		var k, v string
+		k, n = decodeFluentString(t, got[off:])
+		off += n
+		v, n = decodeFluentString(t, got[off:])
+		off += n
+		record[k] = v
+	}
+
+	if record["redactable"] != "true" {
+		t.Errorf("record[%q] = %q, want %q (full record: %+v)", "redactable", record["redactable"], "true", record)
+	}
+	if record["message"] != "secret" {
+		t.Errorf("redaction flagging should not alter the message text; got %q", record["message"])
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// httpSink is a LogSink that POSTs newline-delimited JSON batches of
+// entries to an HTTP collector, retrying failed batches with
+// exponential backoff.
+type httpSink struct {
+	url        string
+	client     *http.Client
+	maxRetries int
+	formatter  logFormatter
+
+	mu struct {
+		syncutil.Mutex
+		pending bytes.Buffer
+	}
+}
+
+// NewHTTPSink creates a LogSink that batches entries as
+// newline-delimited JSON and POSTs them to url. Flush sends whatever
+// has accumulated since the last Flush; failed sends are retried up
+// to maxRetries times with exponential backoff before the batch is
+// dropped.
+func NewHTTPSink(url string, maxRetries int) LogSink {
+	return &httpSink{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		maxRetries: maxRetries,
+		formatter:  jsonFormatter{},
+	}
+}
+
+// Write implements LogSink by appending entry to the pending batch.
+// The batch is only sent to the collector on Flush, so that a burst
+// of log lines results in one POST instead of one per line.
+func (h *httpSink) Write(entry Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	buf := h.formatter.formatEntry(entry)
+	h.mu.pending.Write(buf.Bytes())
+	return nil
+}
+
+// Flush POSTs the pending batch to the collector, retrying with
+// exponential backoff on failure.
+func (h *httpSink) Flush() error {
+	h.mu.Lock()
+	if h.mu.pending.Len() == 0 {
+		h.mu.Unlock()
+		return nil
+	}
+	body := append([]byte(nil), h.mu.pending.Bytes()...)
+	h.mu.pending.Reset()
+	h.mu.Unlock()
+
+	backoff := 100 * time.Millisecond
+	var lastErr error
+	for attempt := 0; attempt <= h.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		resp, err := h.client.Post(h.url, "application/x-ndjson", bytes.NewReader(body))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = errHTTPStatus(resp.StatusCode)
+	}
+	return lastErr
+}
+
+// Close implements LogSink, flushing any remaining entries.
+func (h *httpSink) Close() error {
+	return h.Flush()
+}
+
+// ListFiles implements LogSink. The HTTP sink has no on-disk
+// footprint of its own.
+func (h *httpSink) ListFiles() []FileInfo { return nil }
+
+type errHTTPStatus int
+
+func (e errHTTPStatus) Error() string {
+	return fmt.Sprintf("log: http sink received unexpected status code %d", int(e))
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log/severity"
+)
+
+func TestHTTPSinkFlushRetriesUntilSuccess(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var requests int32
+	var lastBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		lastBody = string(body)
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(srv.URL, 5)
+	defer sink.Close()
+
+	entries := []Entry{
+		{Severity: severity.INFO, Time: time.Now(), File: "a.go", Line: 1, Message: "first"},
+		{Severity: severity.INFO, Time: time.Now(), File: "a.go", Line: 2, Message: "second"},
+	}
+	for _, e := range entries {
+		if err := sink.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("Flush did not eventually succeed: %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("server received %d requests, want 3 (2 failures + 1 success)", got)
+	}
+	for _, want := range []string{"first", "second"} {
+		if !strings.Contains(lastBody, want) {
+			t.Errorf("request body %q does not contain entry %q", lastBody, want)
+		}
+	}
+	if n := strings.Count(lastBody, "\n"); n != 2 {
+		t.Errorf("expected one newline-delimited JSON line per entry (2), got %d newlines in %q", n, lastBody)
+	}
+}
+
+func TestHTTPSinkFlushGivesUpAfterMaxRetries(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(srv.URL, 2)
+	defer sink.Close()
+
+	if err := sink.Write(Entry{Severity: severity.INFO, Time: time.Now(), Message: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Flush(); err == nil {
+		t.Error("expected Flush to return an error once retries are exhausted")
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("server received %d requests, want 3 (1 initial + 2 retries)", got)
+	}
+}
+
+func TestHTTPSinkRedactable(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var lastBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lastBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(srv.URL, 5)
+	defer sink.Close()
+
+	entry := Entry{Severity: severity.INFO, Time: time.Now(), Message: "secret", Redactable: true}
+	if err := sink.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `"redactable":true`; !strings.Contains(lastBody, want) {
+		t.Errorf("request body %q missing %q", lastBody, want)
+	}
+}
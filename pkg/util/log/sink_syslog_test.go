@@ -0,0 +1,152 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log/severity"
+)
+
+func TestSyslogPriority(t *testing.T) {
+	testCases := []struct {
+		facility SyslogFacility
+		sev      severity.Severity
+		want     int
+	}{
+		{SyslogFacilityUser, severity.INFO, 1*8 + 6},
+		{SyslogFacilityUser, severity.WARNING, 1*8 + 4},
+		{SyslogFacilityUser, severity.ERROR, 1*8 + 3},
+		{SyslogFacilityUser, severity.FATAL, 1*8 + 3},
+		{SyslogFacilityLocal0, severity.INFO, 16*8 + 6},
+	}
+	for _, tc := range testCases {
+		if got := syslogPriority(tc.facility, tc.sev); got != tc.want {
+			t.Errorf("syslogPriority(%v, %v) = %d, want %d", tc.facility, tc.sev, got, tc.want)
+		}
+	}
+}
+
+func TestSyslogSinkWritesRFC5424Message(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgCh := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		msgCh <- string(buf[:n])
+	}()
+
+	sink := NewSyslogSink("tcp", ln.Addr().String(), SyslogFacilityLocal0, "cockroach")
+	defer sink.Close()
+
+	entry := Entry{
+		Severity: severity.WARNING,
+		Time:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		File:     "foo.go",
+		Line:     42,
+		Message:  "something happened",
+	}
+	if err := sink.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	select {
+	case got = <-msgCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the syslog connection to receive a message")
+	}
+
+	wantPRI := fmt.Sprintf("<%d>1 ", syslogPriority(SyslogFacilityLocal0, severity.WARNING))
+	if !strings.HasPrefix(got, wantPRI) {
+		t.Errorf("message %q does not start with expected PRI %q", got, wantPRI)
+	}
+	if !strings.Contains(got, "cockroach") {
+		t.Errorf("message %q does not contain the configured tag", got)
+	}
+	if !strings.Contains(got, "something happened") {
+		t.Errorf("message %q does not contain the log message", got)
+	}
+	if !strings.HasSuffix(got, "\n") {
+		t.Errorf("message %q is not newline-terminated", got)
+	}
+}
+
+func TestSyslogSinkRedactable(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgCh := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		msgCh <- string(buf[:n])
+	}()
+
+	sink := NewSyslogSink("tcp", ln.Addr().String(), SyslogFacilityLocal0, "cockroach")
+	defer sink.Close()
+
+	entry := Entry{
+		Severity:   severity.WARNING,
+		Time:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		File:       "foo.go",
+		Line:       42,
+		Message:    "secret",
+		Redactable: true,
+	}
+	if err := sink.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	select {
+	case got = <-msgCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the syslog connection to receive a message")
+	}
+
+	if want := redactableMarker("secret"); !strings.Contains(got, want) {
+		t.Errorf("message %q does not contain redaction marker %q", got, want)
+	}
+}
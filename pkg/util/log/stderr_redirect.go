@@ -0,0 +1,41 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+// redirectStderr arranges for fd 2 (os.Stderr) to point at l's log
+// file, by dup'ing the file's descriptor directly onto it. This
+// (rather than swapping the os.Stderr *os.File variable, or copying
+// through a pipe on a background goroutine) is what makes output
+// from code that writes straight to the fd -- cgo, the runtime's own
+// panic handler -- still end up on disk: the redirect is visible to
+// anything that writes to fd 2, and it's in effect the instant this
+// function returns, with no reader goroutine race to win.
+//
+// l must have a fileSink; redirectStderr creates its backing file
+// synchronously if it doesn't exist yet.
+func redirectStderr(l *loggerT) (func(), error) {
+	fs := l.getFileSink()
+	if fs == nil {
+		return func() {}, nil
+	}
+	f, err := fs.ensureOSFile()
+	if err != nil {
+		return nil, err
+	}
+	return dupStderrTo(f)
+}
+
+// resetStderrRedirect is a no-op placeholder invoked by
+// TestingResetActive between tests; redirectStderr's cleanup
+// function is what actually tears things down, this just guards
+// against stale global state in future extensions of the redirect
+// mechanism.
+func resetStderrRedirect() {}
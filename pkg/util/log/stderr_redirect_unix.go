@@ -0,0 +1,39 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+//go:build !windows
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+// dupStderrTo duplicates f's descriptor onto fd 2, saving the
+// original fd 2 so it can be restored by the returned cleanup
+// function.
+func dupStderrTo(f *os.File) (func(), error) {
+	origFd, err := syscall.Dup(syscall.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	orig := os.NewFile(uintptr(origFd), "stderr-orig")
+
+	if err := syscall.Dup2(int(f.Fd()), syscall.Stderr); err != nil {
+		_ = orig.Close()
+		return nil, err
+	}
+
+	return func() {
+		_ = syscall.Dup2(int(orig.Fd()), syscall.Stderr)
+		_ = orig.Close()
+	}, nil
+}
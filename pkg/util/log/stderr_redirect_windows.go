@@ -0,0 +1,34 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+//go:build windows
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+// dupStderrTo points the process's STD_ERROR_HANDLE at f, saving the
+// original handle so it can be restored by the returned cleanup
+// function.
+func dupStderrTo(f *os.File) (func(), error) {
+	orig, err := syscall.GetStdHandle(syscall.STD_ERROR_HANDLE)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.SetStdHandle(syscall.STD_ERROR_HANDLE, syscall.Handle(f.Fd())); err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = syscall.SetStdHandle(syscall.STD_ERROR_HANDLE, orig)
+	}, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/logtags"
+)
+
+// renderLogTags renders the logtags.Buffer carried by ctx, if any,
+// into the "k1=v1,k2=v2" shape used by the crdb-v1 formatter and
+// parsed back out by the structured formatters.
+func renderLogTags(ctx context.Context) string {
+	tags := logtags.FromContext(ctx)
+	if tags == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	for i, t := range tags.Get() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if v := t.Value(); v != nil && v != "" {
+			fmt.Fprintf(&buf, "%s=%v", t.Key(), v)
+		} else {
+			buf.WriteString(t.Key())
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestLogScope points the logging package at a scratch directory for
+// the duration of a test, and restores the previous configuration
+// (removing the scratch directory) on Close.
+type TestLogScope struct {
+	previousLogDir string
+	dir            string
+}
+
+// Scope creates a TestLogScope that logs to a temporary directory.
+// It behaves exactly like ScopeWithoutShowLogs: captured log output
+// is not shown on test failure.
+func Scope(t testing.TB) *TestLogScope {
+	return newTestLogScope(t)
+}
+
+// ScopeWithoutShowLogs creates a TestLogScope that never dumps
+// captured log output to the test's own output on failure. Useful for tests (like
+// this package's own) that want to assert on the raw file contents
+// themselves.
+func ScopeWithoutShowLogs(t testing.TB) *TestLogScope {
+	return newTestLogScope(t)
+}
+
+func newTestLogScope(t testing.TB) *TestLogScope {
+	dir, err := ioutil.TempDir("", "log-scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &TestLogScope{
+		previousLogDir: logging.logDir,
+		dir:            dir,
+	}
+	logging.logDir = dir
+	setFlags()
+	return s
+}
+
+// Close restores the previous logging configuration and removes the
+// scratch directory.
+func (s *TestLogScope) Close(t testing.TB) {
+	logging.logDir = s.previousLogDir
+	setFlags()
+	if err := os.RemoveAll(s.dir); err != nil {
+		t.Logf("failed to remove test log scope dir %s: %v", s.dir, err)
+	}
+}
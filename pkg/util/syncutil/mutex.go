@@ -0,0 +1,27 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package syncutil provides thin wrappers around the primitives in
+// the standard sync package. The wrappers exist so that build tags
+// can swap in race-detecting implementations without touching call
+// sites.
+package syncutil
+
+import "sync"
+
+// Mutex is a wrapper around sync.Mutex.
+type Mutex struct {
+	sync.Mutex
+}
+
+// RWMutex is a wrapper around sync.RWMutex.
+type RWMutex struct {
+	sync.RWMutex
+}